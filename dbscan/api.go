@@ -0,0 +1,240 @@
+package dbscan
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var matchFirstCap = regexp.MustCompile("(.)([A-Z][a-z]+)")
+var matchAllCap = regexp.MustCompile("([a-z0-9])([A-Z])")
+
+func toSnakeCase(str string) string {
+	snake := matchFirstCap.ReplaceAllString(str, "${1}_${2}")
+	snake = matchAllCap.ReplaceAllString(snake, "${1}_${2}")
+	return strings.ToLower(snake)
+}
+
+// API holds the struct-mapping configuration ScanAll/ScanOne/ScanRow/
+// RowScanner use, plus the per-type mapping cache it's built against. The
+// package-level ScanAll etc. are backed by DefaultAPI; use NewAPI to
+// customize the struct tag key, column separator, field-name mapper, or
+// unknown-column handling instead.
+type API struct {
+	structTagKey    string
+	altStructTagKey string
+	columnSeparator string
+	fieldMapperFn   func(string) string
+	allowUnknown    bool
+	structTagPrefix bool
+
+	cache sync.Map // reflect.Type -> []fieldMapping
+}
+
+// APIOption customizes NewAPI.
+type APIOption func(*API)
+
+// WithStructTagKey overrides the struct tag key used to look up a field's
+// column name (default "db").
+func WithStructTagKey(tagKey string) APIOption {
+	return func(api *API) { api.structTagKey = tagKey }
+}
+
+// WithColumnSeparator overrides the separator joining a tagged nested
+// struct's prefix to its own fields' column names (default ".").
+func WithColumnSeparator(separator string) APIOption {
+	return func(api *API) { api.columnSeparator = separator }
+}
+
+// WithFieldNameMapper overrides the default field-name-to-column-name
+// mapper (default snake_case) used for fields with no struct tag.
+func WithFieldNameMapper(fn func(string) string) APIOption {
+	return func(api *API) { api.fieldMapperFn = fn }
+}
+
+// WithAllowUnknownColumns makes ScanAll/ScanOne/ScanRow silently ignore
+// result columns with no matching destination field, instead of the
+// default of returning an error.
+func WithAllowUnknownColumns(allow bool) APIOption {
+	return func(api *API) { api.allowUnknown = allow }
+}
+
+// WithStructTagPrefix controls how a nested, non-embedded struct field is
+// treated. With it enabled (the default pgxscan.NewAPI requests), an
+// untagged nested struct's own fields are reachable by prefixing them with
+// the field's mapped name (e.g. "user.id"), the same way a tagged one is
+// reachable by its tag - letting a JOIN result scan straight into several
+// nested structs. Disabled, an untagged nested non-embedded struct is mapped
+// as a single column named by the field's mapped name.
+func WithStructTagPrefix(enabled bool) APIOption {
+	return func(api *API) { api.structTagPrefix = enabled }
+}
+
+// NewAPI builds an API from opts, defaulting to the "db" struct tag (with
+// "pgx" as a fallback), "." as the column separator, snake_case field
+// mapping, and struct-tag-prefixed nested structs disabled.
+func NewAPI(opts ...APIOption) (*API, error) {
+	api := &API{
+		structTagKey:    "db",
+		altStructTagKey: "pgx",
+		columnSeparator: ".",
+		fieldMapperFn:   toSnakeCase,
+	}
+	for _, opt := range opts {
+		opt(api)
+	}
+	return api, nil
+}
+
+// DefaultAPI is the API instance backing the package-level ScanAll, ScanOne,
+// ScanRow and NewRowScanner functions.
+var DefaultAPI = mustNewAPI()
+
+func mustNewAPI() *API {
+	api, err := NewAPI()
+	if err != nil {
+		panic(err)
+	}
+	return api
+}
+
+func (api *API) mapFieldName(name string) string {
+	return api.fieldMapperFn(name)
+}
+
+// NewRowScanner returns a RowScanner backed by api.
+func (api *API) NewRowScanner(rows Rows) *RowScanner {
+	return &RowScanner{api: api, rows: rows}
+}
+
+// scanPlan resolves each of cols to the fieldMapping it scans into, or nil
+// if it has none and unknown columns are allowed.
+func (api *API) scanPlan(t reflect.Type, cols []string) ([]*fieldMapping, error) {
+	byColumn := make(map[string]*fieldMapping)
+	mappings := api.fieldMappings(t)
+	for i := range mappings {
+		byColumn[mappings[i].column] = &mappings[i]
+	}
+
+	plan := make([]*fieldMapping, len(cols))
+	for i, col := range cols {
+		m, ok := byColumn[col]
+		if !ok {
+			if api.allowUnknown {
+				continue
+			}
+			return nil, errors.Errorf("dbscan: column %q has no destination field in %s", col, t)
+		}
+		plan[i] = m
+	}
+	return plan, nil
+}
+
+// scanInto scans the current row of rows - the caller must have already
+// advanced it with rows.Next() - into elem, the addressable struct value a
+// destination pointer points to.
+func (api *API) scanInto(elem reflect.Value, rows Rows) error {
+	cols, err := rows.Columns()
+	if err != nil {
+		return errors.Wrap(err, "dbscan: getting columns")
+	}
+	plan, err := api.scanPlan(elem.Type(), cols)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	dests := make([]interface{}, len(cols))
+	finalizers := make([]func() error, len(cols))
+	for i, m := range plan {
+		if m == nil {
+			var ignored interface{}
+			dests[i] = &ignored
+			finalizers[i] = func() error { return nil }
+			continue
+		}
+		fv := allocFieldByIndex(elem, m.index)
+		dests[i], finalizers[i] = scanTarget(fv, m.kind)
+	}
+	if err := rows.Scan(dests...); err != nil {
+		return errors.Wrap(err, "dbscan: scan row")
+	}
+	for _, finalize := range finalizers {
+		if err := finalize(); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// ScanAll scans every row of rows into dst, a pointer to a slice of
+// structs, closing rows once done. Nested struct fields - embedded, or
+// reached through a tagged (and, with WithStructTagPrefix, untagged)
+// non-embedded field - are matched against dotted column names, e.g. a
+// `db:"user"` Org field maps columns "user.id", "user.name", ...
+func (api *API) ScanAll(dst interface{}, rows Rows) error {
+	defer rows.Close()
+
+	sliceVal := reflect.ValueOf(dst)
+	if sliceVal.Kind() != reflect.Ptr || sliceVal.Elem().Kind() != reflect.Slice {
+		return errors.Errorf("dbscan: ScanAll expects a pointer to a slice, got %T", dst)
+	}
+	elemType := sliceVal.Elem().Type().Elem()
+
+	out := reflect.MakeSlice(sliceVal.Elem().Type(), 0, 0)
+	for rows.Next() {
+		elem := reflect.New(elemType)
+		if err := api.scanInto(elem.Elem(), rows); err != nil {
+			return err
+		}
+		out = reflect.Append(out, elem.Elem())
+	}
+	sliceVal.Elem().Set(out)
+	return errors.WithStack(rows.Err())
+}
+
+// ScanOne is ScanAll for a single expected row. It returns an error for
+// which NotFound is true if rows has none, and a plain error if it has more
+// than one.
+func (api *API) ScanOne(dst interface{}, rows Rows) error {
+	dstVal := reflect.ValueOf(dst)
+	if dstVal.Kind() != reflect.Ptr || dstVal.IsNil() {
+		return errors.Errorf("dbscan: ScanOne expects a non-nil pointer, got %T", dst)
+	}
+	sliceType := reflect.SliceOf(dstVal.Type().Elem())
+	slicePtr := reflect.New(sliceType)
+	if err := api.ScanAll(slicePtr.Interface(), rows); err != nil {
+		return err
+	}
+
+	slice := slicePtr.Elem()
+	switch slice.Len() {
+	case 0:
+		return errNotFound
+	case 1:
+		dstVal.Elem().Set(slice.Index(0))
+		return nil
+	default:
+		return errors.Errorf("dbscan: expected 1 row, got %d", slice.Len())
+	}
+}
+
+// ScanRow scans a single row out of rows - advancing it itself, unlike
+// RowScanner.Scan - into dst, a pointer to a struct. It does not close
+// rows, since a caller scanning one row at a time with ScanRow is expected
+// to do so itself once done.
+func (api *API) ScanRow(dst interface{}, rows Rows) error {
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return errors.WithStack(err)
+		}
+		return errNotFound
+	}
+	dstVal := reflect.ValueOf(dst)
+	if dstVal.Kind() != reflect.Ptr || dstVal.IsNil() {
+		return errors.Errorf("dbscan: ScanRow expects a non-nil pointer, got %T", dst)
+	}
+	return errors.WithStack(api.scanInto(dstVal.Elem(), rows))
+}
@@ -0,0 +1,99 @@
+package dbscan
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+// fakeRows is a Rows backed by an in-memory table, so ScanAll/ScanOne can be
+// exercised end to end without a real database connection.
+type fakeRows struct {
+	cols []string
+	data [][]interface{}
+	idx  int
+}
+
+func (r *fakeRows) Columns() ([]string, error) { return r.cols, nil }
+func (r *fakeRows) Close() error               { return nil }
+func (r *fakeRows) Err() error                 { return nil }
+
+func (r *fakeRows) Next() bool {
+	r.idx++
+	return r.idx <= len(r.data)
+}
+
+func (r *fakeRows) Scan(dest ...interface{}) error {
+	row := r.data[r.idx-1]
+	for i, d := range dest {
+		if scanner, ok := d.(sql.Scanner); ok {
+			if err := scanner.Scan(row[i]); err != nil {
+				return err
+			}
+			continue
+		}
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(row[i]))
+	}
+	return nil
+}
+
+// TestScanAll_JoinWithNestedStructsAndNullableField scans a 3-way JOIN's
+// dotted columns into untagged, non-embedded nested structs - the headline
+// feature WithStructTagPrefix adds - including a sql.NullString field on
+// one of them, the common shape of a nullable, outer-joined column. This
+// also guards against IsScanTarget's nested-struct exclusion regressing:
+// sql.NullString must be scanned as a single field, not decomposed into
+// "address.zip.string"/"address.zip.valid" dotted columns.
+func TestScanAll_JoinWithNestedStructsAndNullableField(t *testing.T) {
+	type User struct {
+		ID   int
+		Name string
+	}
+	type Org struct {
+		ID   int
+		Name string
+	}
+	type Address struct {
+		City string
+		Zip  sql.NullString
+	}
+	type Row struct {
+		User    User
+		Org     Org
+		Address Address
+	}
+
+	api, err := NewAPI(WithStructTagPrefix(true))
+	if err != nil {
+		t.Fatalf("NewAPI returned an error: %v", err)
+	}
+
+	rows := &fakeRows{
+		cols: []string{"user.id", "user.name", "org.id", "org.name", "address.city", "address.zip"},
+		data: [][]interface{}{
+			{1, "Alice", 10, "Acme", "NYC", nil},
+			{2, "Bob", 20, "Globex", "Boston", "02101"},
+		},
+	}
+
+	var got []Row
+	if err := api.ScanAll(&got, rows); err != nil {
+		t.Fatalf("ScanAll returned an error: %v", err)
+	}
+
+	want := []Row{
+		{
+			User:    User{ID: 1, Name: "Alice"},
+			Org:     Org{ID: 10, Name: "Acme"},
+			Address: Address{City: "NYC", Zip: sql.NullString{}},
+		},
+		{
+			User:    User{ID: 2, Name: "Bob"},
+			Org:     Org{ID: 20, Name: "Globex"},
+			Address: Address{City: "Boston", Zip: sql.NullString{String: "02101", Valid: true}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ScanAll result = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+// Package dbscan scans database rows into structs, independent of any
+// particular driver. pgxscan adapts pgx.Rows to the Rows interface this
+// package needs and layers pgx-specific conveniences (Querier, Insert,
+// NamedExec, ...) on top.
+package dbscan
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// Rows is the subset of a driver's row-iteration type dbscan needs to scan
+// results. *pgxscan.RowsAdapter implements this for pgx.Rows.
+type Rows interface {
+	Columns() ([]string, error)
+	Close() error
+	Err() error
+	Next() bool
+	Scan(dest ...interface{}) error
+}
+
+var errNotFound = errors.New("dbscan: no row found")
+
+// NotFound reports whether err is the error ScanOne/ScanRow return when a
+// query produced no rows.
+func NotFound(err error) bool {
+	return errors.Is(err, errNotFound)
+}
+
+// RowScanner scans individual rows of an already-iterated Rows one at a
+// time, for callers that need to interleave scanning with their own
+// rows.Next() loop instead of collecting every row via ScanAll.
+type RowScanner struct {
+	api  *API
+	rows Rows
+}
+
+// NewRowScanner returns a RowScanner backed by the default API. Call
+// rows.Next() yourself before each Scan.
+func NewRowScanner(rows Rows) *RowScanner {
+	return DefaultAPI.NewRowScanner(rows)
+}
+
+// Scan scans the current row of rs.rows - the caller must have already
+// advanced it with rows.Next() - into dst, a pointer to a struct.
+func (rs *RowScanner) Scan(dst interface{}) error {
+	dstVal := reflect.ValueOf(dst)
+	if dstVal.Kind() != reflect.Ptr || dstVal.IsNil() {
+		return errors.Errorf("dbscan: RowScanner.Scan expects a non-nil pointer, got %T", dst)
+	}
+	return errors.WithStack(rs.api.scanInto(dstVal.Elem(), rs.rows))
+}
+
+// ScanAll is a wrapper around DefaultAPI.ScanAll.
+func ScanAll(dst interface{}, rows Rows) error {
+	return DefaultAPI.ScanAll(dst, rows)
+}
+
+// ScanOne is a wrapper around DefaultAPI.ScanOne.
+func ScanOne(dst interface{}, rows Rows) error {
+	return DefaultAPI.ScanOne(dst, rows)
+}
+
+// ScanRow is a wrapper around DefaultAPI.ScanRow.
+func ScanRow(dst interface{}, rows Rows) error {
+	return DefaultAPI.ScanRow(dst, rows)
+}
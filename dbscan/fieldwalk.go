@@ -0,0 +1,146 @@
+package dbscan
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// IsScanTarget reports whether t should be treated as a single opaque
+// column value during struct-to-column mapping rather than decomposed
+// field-by-field, even though it's a struct. This is true for time.Time
+// and for any type - or pointer to it - implementing sql.Scanner or
+// driver.Valuer, e.g. sql.NullString, sql.NullTime, sql.NullBool, or a
+// pgtype.* wrapper: those are exactly the types a caller expects to bind
+// one column to, not to have their own (unexported, in the sql.Null*
+// case) fields fanned out into dotted sub-columns.
+func IsScanTarget(t reflect.Type) bool {
+	if t == timeType {
+		return true
+	}
+	if t.Implements(valuerType) || t.Implements(scannerType) {
+		return true
+	}
+	ptr := reflect.PtrTo(t)
+	return ptr.Implements(scannerType) || ptr.Implements(valuerType)
+}
+
+// FieldWalkConfig configures WalkFields. It mirrors the subset of API's
+// own configuration that affects how struct fields resolve to column
+// names, so dbscan's read-side mapper and pgxscan's write-side one can
+// drive the same traversal from their own (slightly different) option
+// sets instead of each re-implementing it.
+type FieldWalkConfig struct {
+	// StructTagKey is the tag key holding a field's column name, e.g. "db".
+	StructTagKey string
+	// AltStructTagKey is consulted if StructTagKey isn't present on a
+	// field, e.g. "pgx". Ignored if empty.
+	AltStructTagKey string
+	// ColumnSeparator joins a nested struct's prefix to its own fields'
+	// column names, e.g. "." for "user.id".
+	ColumnSeparator string
+	// FieldMapper converts a Go field name to its default column name for
+	// fields with no struct tag, e.g. snake_case.
+	FieldMapper func(string) string
+	// StructTagPrefix controls whether an untagged, non-embedded nested
+	// struct field is decomposed too, with its own fields prefixed by the
+	// field's mapped name, instead of being left as a single leaf field.
+	StructTagPrefix bool
+}
+
+// LeafField is one exported field WalkFields resolved to a single scan or
+// bind target: its dotted column name, the reflect index path
+// allocFieldByIndex/fieldByIndex need to reach it from the root struct
+// value, and its struct tag's options (e.g. "json", "jsonb", "array").
+type LeafField struct {
+	Column string
+	Index  []int
+	Opts   []string
+}
+
+// WalkFields walks t's exported fields per cfg, flattening embedded
+// structs - and any struct field tagged with a column-name prefix,
+// embedded or not - into dotted leaf columns, and calls fn for each leaf
+// field found. A nested struct field is never decomposed if IsScanTarget
+// is true for its type: time.Time and any sql.Scanner/driver.Valuer
+// implementor (sql.NullString and friends) are always a single leaf field,
+// regardless of cfg.StructTagPrefix. Nor is one whose tag carries a "json"
+// or "jsonb" option: it is a single leaf field holding the whole encoded
+// value.
+//
+// prefix and index are the column prefix and reflect index path
+// accumulated so far; callers walking from a struct's root pass "" and
+// nil.
+func WalkFields(t reflect.Type, cfg FieldWalkConfig, prefix string, index []int, fn func(LeafField)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue // unexported
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+
+		tagVal, tagged := field.Tag.Lookup(cfg.StructTagKey)
+		if !tagged && cfg.AltStructTagKey != "" {
+			tagVal, tagged = field.Tag.Lookup(cfg.AltStructTagKey)
+		}
+		parts := strings.Split(tagVal, ",")
+		name, opts := parts[0], parts[1:]
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		jsonEncoded := false
+		for _, opt := range opts {
+			if opt == "json" || opt == "jsonb" {
+				jsonEncoded = true
+			}
+		}
+
+		if fieldType.Kind() == reflect.Struct && !IsScanTarget(fieldType) && !jsonEncoded {
+			if tagged && name == "-" {
+				continue
+			}
+			switch {
+			case field.Anonymous && !tagged:
+				// Untagged embedded struct: flatten with no added prefix.
+				WalkFields(fieldType, cfg, prefix, fieldIndex, fn)
+				continue
+			case tagged:
+				// Tagged struct, embedded or not: the tag becomes a column
+				// prefix for the nested struct's own fields, e.g. a
+				// `db:"user"` Org field maps "user.id", "user.name", ...
+				WalkFields(fieldType, cfg, prefix+name+cfg.ColumnSeparator, fieldIndex, fn)
+				continue
+			case cfg.StructTagPrefix:
+				// Untagged, non-embedded nested struct: default the prefix
+				// to its own mapped field name rather than rejecting it, so
+				// a JOIN result can scan straight into nested structs.
+				WalkFields(fieldType, cfg, prefix+cfg.FieldMapper(field.Name)+cfg.ColumnSeparator, fieldIndex, fn)
+				continue
+			default:
+				// cfg.StructTagPrefix is off: nested non-embedded structs
+				// are only reachable via an explicit tag, handled above.
+			}
+		}
+
+		if tagged {
+			if name == "-" {
+				continue
+			}
+		} else {
+			name = cfg.FieldMapper(field.Name)
+		}
+		fn(LeafField{Column: prefix + name, Index: fieldIndex, Opts: opts})
+	}
+}
@@ -0,0 +1,161 @@
+package dbscan
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// fieldKind picks how a scanned column value is turned into its struct
+// field, mirroring the "json"/"jsonb"/"array" tag options pgxscan's mapper.go
+// accepts on the write side.
+type fieldKind int
+
+const (
+	fieldDefault fieldKind = iota
+	fieldJSON
+	fieldArray
+)
+
+// fieldMapping describes one leaf column a destination struct can be scanned
+// from: its resolved column name (dotted, if reached through a tagged nested
+// struct - see structTagPrefix), the index path allocFieldByIndex needs to
+// reach it, and how to turn the scanned value into the field.
+type fieldMapping struct {
+	column string
+	index  []int
+	kind   fieldKind
+}
+
+// fieldMappings returns t's column mapping, building and caching it on
+// first use. The mapping is scoped to api so different APIs (e.g. a
+// different struct tag key or field mapper) never share a cache entry.
+func (api *API) fieldMappings(t reflect.Type) []fieldMapping {
+	if cached, ok := api.cache.Load(t); ok {
+		return cached.([]fieldMapping)
+	}
+	mappings := api.buildFieldMappings(t)
+	actual, _ := api.cache.LoadOrStore(t, mappings)
+	return actual.([]fieldMapping)
+}
+
+// buildFieldMappings walks t via the shared WalkFields traversal, using
+// api's own tag/separator/mapper configuration, and turns each leaf field
+// it finds into a fieldMapping.
+func (api *API) buildFieldMappings(t reflect.Type) []fieldMapping {
+	cfg := FieldWalkConfig{
+		StructTagKey:    api.structTagKey,
+		AltStructTagKey: api.altStructTagKey,
+		ColumnSeparator: api.columnSeparator,
+		FieldMapper:     api.fieldMapperFn,
+		StructTagPrefix: api.structTagPrefix,
+	}
+	var out []fieldMapping
+	WalkFields(t, cfg, "", nil, func(lf LeafField) {
+		out = append(out, fieldMapping{column: lf.Column, index: lf.Index, kind: kindForOpts(lf.Opts)})
+	})
+	return out
+}
+
+// kindForOpts inspects a field's tag options and picks how its scanned
+// value should be decoded into the field.
+func kindForOpts(opts []string) fieldKind {
+	for _, opt := range opts {
+		switch opt {
+		case "json", "jsonb":
+			return fieldJSON
+		case "array":
+			return fieldArray
+		}
+	}
+	return fieldDefault
+}
+
+// scanTarget returns the value Rows.Scan should write a column into for fv,
+// and a finalize func to call afterwards to decode that value into fv - a
+// no-op for fieldDefault, since pgx already scans straight into fv's type.
+func scanTarget(fv reflect.Value, kind fieldKind) (interface{}, func() error) {
+	switch kind {
+	case fieldJSON:
+		var raw []byte
+		return &raw, func() error {
+			if raw == nil {
+				return nil
+			}
+			return errors.Wrap(json.Unmarshal(raw, fv.Addr().Interface()), "dbscan: decoding json field")
+		}
+	case fieldArray:
+		var raw string
+		return &raw, func() error {
+			return decodeArray(raw, fv)
+		}
+	default:
+		return fv.Addr().Interface(), func() error { return nil }
+	}
+}
+
+// decodeArray parses a Postgres array literal ("{a,b,c}") produced by
+// pgxscan's encodeArray back into fv, a slice field tagged "...,array".
+func decodeArray(raw string, fv reflect.Value) error {
+	if fv.Kind() != reflect.Slice {
+		return errors.Errorf(`dbscan: "array" tag on non-slice field of type %s`, fv.Type())
+	}
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimPrefix(raw, "{")
+	raw = strings.TrimSuffix(raw, "}")
+
+	result := reflect.MakeSlice(fv.Type(), 0, 0)
+	if raw != "" {
+		elemType := fv.Type().Elem()
+		for _, part := range splitArrayLiteral(raw) {
+			elem := reflect.New(elemType)
+			if err := json.Unmarshal([]byte(part), elem.Interface()); err != nil {
+				return errors.Wrap(err, "dbscan: decoding array element")
+			}
+			result = reflect.Append(result, elem.Elem())
+		}
+	}
+	fv.Set(result)
+	return nil
+}
+
+// splitArrayLiteral splits the body of a Postgres array literal on commas
+// that aren't inside a double-quoted element.
+func splitArrayLiteral(s string) []string {
+	var parts []string
+	var cur strings.Builder
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+			cur.WriteByte(c)
+		case c == ',' && !inQuotes:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	parts = append(parts, cur.String())
+	return parts
+}
+
+// allocFieldByIndex is like reflect.Value.FieldByIndex, except it allocates
+// a nested pointer in place of a nil one instead of panicking, since a scan
+// destination must exist before Rows.Scan can write into it.
+func allocFieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
@@ -0,0 +1,94 @@
+package dbscan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeArray(t *testing.T) {
+	cases := []struct {
+		name     string
+		raw      string
+		dst      interface{}
+		expected interface{}
+	}{
+		{
+			name:     "empty array",
+			raw:      "{}",
+			dst:      &[]string{},
+			expected: &[]string{},
+		},
+		{
+			name:     "strings",
+			raw:      `{"a","b","c"}`,
+			dst:      &[]string{},
+			expected: &[]string{"a", "b", "c"},
+		},
+		{
+			name:     "ints",
+			raw:      "{1,2,3}",
+			dst:      &[]int{},
+			expected: &[]int{1, 2, 3},
+		},
+		{
+			name:     "string containing a comma",
+			raw:      `{"a,b","c"}`,
+			dst:      &[]string{},
+			expected: &[]string{"a,b", "c"},
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			fv := reflect.ValueOf(tc.dst).Elem()
+			if err := decodeArray(tc.raw, fv); err != nil {
+				t.Fatalf("decodeArray returned an error: %v", err)
+			}
+			if !reflect.DeepEqual(tc.dst, tc.expected) {
+				t.Errorf("decodeArray(%q) = %v, want %v", tc.raw, tc.dst, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDecodeArray_NonSliceField_ReturnsErr(t *testing.T) {
+	var dst string
+	fv := reflect.ValueOf(&dst).Elem()
+	err := decodeArray("{1}", fv)
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+}
+
+func TestScanTarget_JSONField_DecodesAfterFinalize(t *testing.T) {
+	type nested struct {
+		Foo string `json:"foo"`
+	}
+	var dst nested
+	fv := reflect.ValueOf(&dst).Elem()
+
+	raw, finalize := scanTarget(fv, fieldJSON)
+	*(raw.(*[]byte)) = []byte(`{"foo":"bar"}`)
+
+	if err := finalize(); err != nil {
+		t.Fatalf("finalize returned an error: %v", err)
+	}
+	if dst.Foo != "bar" {
+		t.Errorf("got %+v, want Foo=bar", dst)
+	}
+}
+
+func TestScanTarget_ArrayField_DecodesAfterFinalize(t *testing.T) {
+	var dst []string
+	fv := reflect.ValueOf(&dst).Elem()
+
+	raw, finalize := scanTarget(fv, fieldArray)
+	*(raw.(*string)) = `{"a","b"}`
+
+	if err := finalize(); err != nil {
+		t.Fatalf("finalize returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(dst, []string{"a", "b"}) {
+		t.Errorf("got %v, want [a b]", dst)
+	}
+}
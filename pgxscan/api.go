@@ -0,0 +1,98 @@
+package pgxscan
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	"github.com/KirksFletcher/scany/dbscan"
+)
+
+// API holds a dbscan.API configuration and exposes Select/Get/ScanAll/
+// ScanOne/ScanRow/NewRowScanner against it, the same way the package-level
+// functions of the same name do against defaultAPI. Build one with NewAPI
+// to customize struct-mapping behavior, e.g. dbscan.WithAllowUnknownColumns
+// or dbscan.WithFieldNameMapper.
+//
+// Non-embedded nested struct fields (e.g. a JOIN result's "user.id",
+// "org.id" columns mapped into a Row{User User; Org Org} struct) are
+// handled by ScanAll/Select themselves: any field - embedded or not -
+// tagged `db:"user"` namespaces its own fields under "user."; see
+// dbscan.WithStructTagPrefix for making this apply to untagged
+// non-embedded struct fields too, which defaultAPI enables.
+type API struct {
+	dbapi *dbscan.API
+}
+
+// NewAPI builds an API. It always uses pgxscan's own default field-name
+// mapper (see SetMapper) unless overridden by an explicit
+// dbscan.WithFieldNameMapper option, and enables dbscan.WithStructTagPrefix
+// unless overridden, so untagged non-embedded nested structs resolve their
+// own fields the same way defaultAPI does.
+func NewAPI(opts ...dbscan.APIOption) (*API, error) {
+	allOpts := append([]dbscan.APIOption{
+		dbscan.WithFieldNameMapper(mapFieldName),
+		dbscan.WithStructTagPrefix(true),
+	}, opts...)
+	dbapi, err := dbscan.NewAPI(allOpts...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &API{dbapi: dbapi}, nil
+}
+
+var defaultAPI = mustNewAPI()
+
+func mustNewAPI() *API {
+	api, err := NewAPI()
+	if err != nil {
+		panic(err)
+	}
+	return api
+}
+
+// Select queries rows from db and scans them into dst via api.ScanAll.
+func (api *API) Select(ctx context.Context, db Querier, dst interface{}, query string, args ...interface{}) error {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "scany: query multiple result rows")
+	}
+	return errors.WithStack(api.ScanAll(dst, rows))
+}
+
+// Get queries rows from db and scans the single expected row into dst via
+// api.ScanOne.
+func (api *API) Get(ctx context.Context, db Querier, dst interface{}, query string, args ...interface{}) error {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "scany: query one result row")
+	}
+	return errors.WithStack(api.ScanOne(dst, rows))
+}
+
+// ScanAll is a wrapper around dbscan.API.ScanAll.
+func (api *API) ScanAll(dst interface{}, rows pgx.Rows) error {
+	return errors.WithStack(api.dbapi.ScanAll(dst, NewRowsAdapter(rows)))
+}
+
+// ScanOne is a wrapper around dbscan.API.ScanOne. If no rows are found it
+// returns a pgx.ErrNoRows error.
+func (api *API) ScanOne(dst interface{}, rows pgx.Rows) error {
+	err := api.dbapi.ScanOne(dst, NewRowsAdapter(rows))
+	if dbscan.NotFound(err) {
+		return errors.WithStack(pgx.ErrNoRows)
+	}
+	return errors.WithStack(err)
+}
+
+// ScanRow is a wrapper around dbscan.API.ScanRow.
+func (api *API) ScanRow(dst interface{}, rows pgx.Rows) error {
+	return errors.WithStack(api.dbapi.ScanRow(dst, NewRowsAdapter(rows)))
+}
+
+// NewRowScanner returns a new RowScanner backed by api.
+func (api *API) NewRowScanner(rows pgx.Rows) *RowScanner {
+	ra := NewRowsAdapter(rows)
+	return &RowScanner{RowScanner: api.dbapi.NewRowScanner(ra)}
+}
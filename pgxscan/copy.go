@@ -0,0 +1,236 @@
+package pgxscan
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+// CopyConn is something CopyFromStructs can drive pgx's COPY protocol
+// through. For example, it can be: *pgx.Conn or *pgxpool.Pool.
+type CopyConn interface {
+	Querier
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+var (
+	_ CopyConn = &pgx.Conn{}
+	_ CopyConn = &pgxpool.Pool{}
+)
+
+// CopyOption customizes CopyFromStructs.
+type CopyOption func(*copyOptions)
+
+type copyOptions struct {
+	columns      []string
+	conflictCols []string
+	returning    *returningOption
+}
+
+type returningOption struct {
+	naturalKey string
+	idColumn   string
+}
+
+// WithCopyColumns restricts the copy to columns, instead of every column
+// Insert would otherwise derive from the struct.
+func WithCopyColumns(columns ...string) CopyOption {
+	return func(o *copyOptions) { o.columns = columns }
+}
+
+// WithCopyOnConflict makes CopyFromStructs degrade to a single batched
+// Upsert keyed on conflictCols instead of using COPY, since PostgreSQL's
+// COPY protocol has no conflict-handling clause of its own.
+func WithCopyOnConflict(conflictCols ...string) CopyOption {
+	return func(o *copyOptions) { o.conflictCols = conflictCols }
+}
+
+// WithCopyReturning follows the copy with a second query that looks rows
+// back up by idColumn keyed on naturalKey, and writes the matched
+// idColumn value into the corresponding element of the rows slice passed
+// to CopyFromStructs - the same way a RETURNING clause hydrates
+// generated IDs for Insert. The matched elements are updated in place, so
+// rows may be a slice of structs, a slice of pointers to struct, or a
+// pointer to either.
+func WithCopyReturning(naturalKey, idColumn string) CopyOption {
+	return func(o *copyOptions) {
+		o.returning = &returningOption{naturalKey: naturalKey, idColumn: idColumn}
+	}
+}
+
+// CopyFromStructs bulk-inserts rows, a slice of structs or pointers to
+// structs, into table using PostgreSQL's COPY protocol, which is
+// dramatically faster than batched INSERTs for large row counts. Column
+// names come from the same db/pgx tag and snake_case rules Insert uses. It
+// returns the number of rows copied.
+func CopyFromStructs(ctx context.Context, conn CopyConn, table string, rows interface{}, opts ...CopyOption) (int64, error) {
+	o := &copyOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	v := reflect.ValueOf(rows)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return 0, errors.New("scany: CopyFromStructs expects a slice of structs")
+	}
+	if v.Len() == 0 {
+		return 0, nil
+	}
+
+	cols := o.columns
+	if len(cols) == 0 {
+		first, err := structFields(v.Index(0).Interface())
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		cols = sortedColumns(first)
+	}
+
+	var affected int64
+	if len(o.conflictCols) > 0 {
+		if err := Upsert(ctx, conn, rows, table, o.conflictCols, WithColumns(cols...)); err != nil {
+			return 0, errors.WithStack(err)
+		}
+		affected = int64(v.Len())
+	} else {
+		// table is spliced verbatim into Insert/InsertMany's "INSERT INTO
+		// %s" SQL text, so it accepts a schema-qualified name like
+		// "public.foo" as-is. pgx.Identifier quotes each of its elements as
+		// one identifier, so split on "." to give it the same shape Insert
+		// gets for free.
+		n, err := conn.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), cols, &structCopySource{rows: v, cols: cols, idx: -1})
+		if err != nil {
+			return 0, errors.Wrap(err, "scany: copy from structs")
+		}
+		affected = n
+	}
+
+	if o.returning != nil {
+		if err := hydrateReturning(ctx, conn, table, v, o.returning); err != nil {
+			return affected, errors.WithStack(err)
+		}
+	}
+	return affected, nil
+}
+
+// structCopySource adapts a slice of structs to pgx.CopyFromSource,
+// encoding each row's values the same way Insert does.
+type structCopySource struct {
+	rows reflect.Value
+	cols []string
+	idx  int
+	err  error
+}
+
+func (s *structCopySource) Next() bool {
+	s.idx++
+	return s.idx < s.rows.Len()
+}
+
+func (s *structCopySource) Values() ([]interface{}, error) {
+	fields, err := structFields(s.rows.Index(s.idx).Interface())
+	if err != nil {
+		s.err = err
+		return nil, err
+	}
+	values := make([]interface{}, len(s.cols))
+	for i, col := range s.cols {
+		values[i] = fields[col]
+	}
+	return values, nil
+}
+
+func (s *structCopySource) Err() error {
+	return s.err
+}
+
+// hydrateReturning looks rows back up by ret.idColumn keyed on
+// ret.naturalKey and writes the matched ret.idColumn value into each
+// element of rows. The lookup's "= ANY($1)" array parameter and the
+// scanned id/key columns are bound to the natural key's and id column's own
+// Go field types rather than interface{}, since pgx v4 has no codec for a
+// bare []interface{} or *interface{} - it needs a concretely-typed slice or
+// pointer to resolve a type for the wire.
+func hydrateReturning(ctx context.Context, db Querier, table string, rows reflect.Value, ret *returningOption) error {
+	elemType := derefType(rows.Index(0).Interface())
+	naturalKeyField, ok := fieldMappingByColumn(elemType, ret.naturalKey)
+	if !ok {
+		return errors.Errorf("scany: natural key column %q not found on %s", ret.naturalKey, elemType)
+	}
+	idField, ok := fieldMappingByColumn(elemType, ret.idColumn)
+	if !ok {
+		return errors.Errorf("scany: id column %q not found on %s", ret.idColumn, elemType)
+	}
+	naturalKeyType := typeByIndex(elemType, naturalKeyField.index)
+	idType := typeByIndex(elemType, idField.index)
+
+	keyToIdx := make(map[interface{}]int, rows.Len())
+	keys := reflect.MakeSlice(reflect.SliceOf(naturalKeyType), 0, rows.Len())
+	for i := 0; i < rows.Len(); i++ {
+		elem := rows.Index(i)
+		for elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		fv := fieldByIndex(elem, naturalKeyField.index)
+		keyToIdx[fv.Interface()] = i
+		keys = reflect.Append(keys, fv)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s, %s FROM %s WHERE %s = ANY($1)",
+		ret.idColumn, ret.naturalKey, table, ret.naturalKey,
+	)
+	matchedRows, err := db.Query(ctx, query, keys.Interface())
+	if err != nil {
+		return errors.Wrap(err, "scany: hydrating returning values")
+	}
+	defer matchedRows.Close()
+
+	for matchedRows.Next() {
+		idPtr := reflect.New(idType)
+		keyPtr := reflect.New(naturalKeyType)
+		if err := matchedRows.Scan(idPtr.Interface(), keyPtr.Interface()); err != nil {
+			return errors.Wrap(err, "scany: scanning returning row")
+		}
+		idx, ok := keyToIdx[keyPtr.Elem().Interface()]
+		if !ok {
+			continue
+		}
+		if err := setIDField(rows.Index(idx), ret.idColumn, idPtr.Elem().Interface()); err != nil {
+			return err
+		}
+	}
+	return errors.WithStack(matchedRows.Err())
+}
+
+func setIDField(elem reflect.Value, idColumn string, idValue interface{}) error {
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	var idMapping *fieldMapping
+	for _, m := range fieldMappings(elem.Type()) {
+		if m.column == idColumn {
+			mCopy := m
+			idMapping = &mCopy
+			break
+		}
+	}
+	if idMapping == nil {
+		return errors.Errorf("scany: id column %q not found on %s", idColumn, elem.Type())
+	}
+
+	fv := fieldByIndex(elem, idMapping.index)
+	if !fv.CanSet() {
+		return errors.Errorf("scany: cannot set %q on %s", idColumn, elem.Type())
+	}
+	fv.Set(reflect.ValueOf(idValue).Convert(fv.Type()))
+	return nil
+}
@@ -0,0 +1,130 @@
+package pgxscan
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// fakeCopyConn is a CopyConn that records the CopyFrom/Query call it was
+// asked to make instead of hitting a real database, so CopyFromStructs'
+// column/conflict handling can be tested without one.
+type fakeCopyConn struct {
+	*fakeQuerier
+	copyTable pgx.Identifier
+	copyCols  []string
+	copyRows  [][]interface{}
+}
+
+func (c *fakeCopyConn) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	c.copyTable = tableName
+	c.copyCols = columnNames
+	for rowSrc.Next() {
+		vals, err := rowSrc.Values()
+		if err != nil {
+			return 0, err
+		}
+		c.copyRows = append(c.copyRows, vals)
+	}
+	if err := rowSrc.Err(); err != nil {
+		return 0, err
+	}
+	return int64(len(c.copyRows)), nil
+}
+
+type copyWidget struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestCopyFromStructs(t *testing.T) {
+	conn := &fakeCopyConn{fakeQuerier: &fakeQuerier{}}
+	rows := []copyWidget{{ID: 1, Name: "foo"}, {ID: 2, Name: "bar"}}
+
+	affected, err := CopyFromStructs(context.Background(), conn, "widgets", rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if affected != 2 {
+		t.Errorf("affected = %d, want 2", affected)
+	}
+	if conn.copyTable.Sanitize() != (pgx.Identifier{"widgets"}).Sanitize() {
+		t.Errorf("copyTable = %v, want %v", conn.copyTable, pgx.Identifier{"widgets"})
+	}
+	wantCols := []string{"id", "name"}
+	if len(conn.copyCols) != len(wantCols) || conn.copyCols[0] != wantCols[0] || conn.copyCols[1] != wantCols[1] {
+		t.Errorf("copyCols = %v, want %v", conn.copyCols, wantCols)
+	}
+	wantRows := [][]interface{}{{1, "foo"}, {2, "bar"}}
+	for i, row := range conn.copyRows {
+		if row[0] != wantRows[i][0] || row[1] != wantRows[i][1] {
+			t.Errorf("copyRows[%d] = %v, want %v", i, row, wantRows[i])
+		}
+	}
+}
+
+func TestCopyFromStructs_WithCopyColumns_RestrictsCopiedColumns(t *testing.T) {
+	conn := &fakeCopyConn{fakeQuerier: &fakeQuerier{}}
+	rows := []copyWidget{{ID: 1, Name: "foo"}}
+
+	if _, err := CopyFromStructs(context.Background(), conn, "widgets", rows, WithCopyColumns("name")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conn.copyCols) != 1 || conn.copyCols[0] != "name" {
+		t.Errorf("copyCols = %v, want [name]", conn.copyCols)
+	}
+	if len(conn.copyRows) != 1 || len(conn.copyRows[0]) != 1 || conn.copyRows[0][0] != "foo" {
+		t.Errorf("copyRows = %v, want [[foo]]", conn.copyRows)
+	}
+}
+
+func TestCopyFromStructs_WithCopyOnConflict_FallsBackToUpsert(t *testing.T) {
+	conn := &fakeCopyConn{fakeQuerier: &fakeQuerier{}}
+	rows := []copyWidget{{ID: 1, Name: "foo"}}
+
+	affected, err := CopyFromStructs(context.Background(), conn, "widgets", rows, WithCopyOnConflict("id"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if affected != 1 {
+		t.Errorf("affected = %d, want 1", affected)
+	}
+	if conn.copyCols != nil {
+		t.Errorf("CopyFrom should not have been called, got copyCols = %v", conn.copyCols)
+	}
+	if !strings.Contains(conn.query, "ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name") {
+		t.Errorf("query = %q, missing expected ON CONFLICT clause", conn.query)
+	}
+}
+
+// TestCopyFromStructs_WithCopyColumnsAndOnConflict_RestrictsUpsertColumns
+// guards the bug where WithCopyColumns' subset was computed but never
+// forwarded to the WithCopyOnConflict fallback's Upsert call, so a caller
+// combining both options silently got every struct field upserted instead
+// of the subset they asked for.
+func TestCopyFromStructs_WithCopyColumnsAndOnConflict_RestrictsUpsertColumns(t *testing.T) {
+	conn := &fakeCopyConn{fakeQuerier: &fakeQuerier{}}
+	rows := []copyWidget{{ID: 1, Name: "foo"}}
+
+	_, err := CopyFromStructs(
+		context.Background(), conn, "widgets", rows,
+		WithCopyColumns("id"), WithCopyOnConflict("id"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(conn.query, "INSERT INTO widgets (id) VALUES") {
+		t.Errorf("query = %q, want an INSERT restricted to the id column", conn.query)
+	}
+	if strings.Contains(conn.query, "name") {
+		t.Errorf("query = %q, should not reference the name column", conn.query)
+	}
+	// Every requested column (just "id") is also the conflict column, so
+	// there's nothing left to set - the generated clause must fall back to
+	// DO NOTHING instead of the invalid "DO UPDATE SET " with nothing after it.
+	if !strings.Contains(conn.query, "ON CONFLICT (id) DO NOTHING") {
+		t.Errorf("query = %q, want a well-formed ON CONFLICT (id) DO NOTHING clause", conn.query)
+	}
+}
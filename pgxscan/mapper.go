@@ -0,0 +1,201 @@
+package pgxscan
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/KirksFletcher/scany/dbscan"
+)
+
+// fieldMapperFunc converts a Go field name to its default column name for
+// fields with no "db" or "pgx" tag. It defaults to toSnakeCase and can be
+// overridden with SetMapper.
+var fieldMapperFunc = toSnakeCase
+var fieldMapperMu sync.RWMutex
+
+// SetMapper overrides the default snake_case field-to-column name mapper
+// used by Insert, InsertMany, Upsert, NamedExec and NamedQuery for fields
+// that have no "db" or "pgx" tag, e.g. to switch to CamelCase or lowercase
+// columns. It must be called before the mapped types are first used, since
+// a type's mapping is cached on first use and is not rebuilt afterwards.
+func SetMapper(fn func(string) string) {
+	fieldMapperMu.Lock()
+	defer fieldMapperMu.Unlock()
+	fieldMapperFunc = fn
+}
+
+func mapFieldName(name string) string {
+	fieldMapperMu.RLock()
+	defer fieldMapperMu.RUnlock()
+	return fieldMapperFunc(name)
+}
+
+// fieldMapping describes one mapped, exported field of a struct type: its
+// column name, the index path reflect.Value.Field needs to reach it
+// (embedded structs are flattened, so the path may be more than one element
+// deep), an optional explicit Postgres cast for its placeholder (e.g.
+// "jsonb", from a "...,jsonb" tag option), and the function used to turn
+// the field's value into the interface{} passed to pgx.
+type fieldMapping struct {
+	column string
+	index  []int
+	cast   string
+	encode func(reflect.Value) (interface{}, error)
+}
+
+// mapperCache memoizes the []fieldMapping for a struct type so its tags and
+// embedded fields are only ever walked once, no matter how many times
+// Insert/NamedExec/etc. are called with that type.
+var mapperCache sync.Map // reflect.Type -> []fieldMapping
+
+// fieldMappings returns t's column mapping, building and caching it on
+// first use.
+func fieldMappings(t reflect.Type) []fieldMapping {
+	if cached, ok := mapperCache.Load(t); ok {
+		return cached.([]fieldMapping)
+	}
+	mappings := buildFieldMappings(t)
+	actual, _ := mapperCache.LoadOrStore(t, mappings)
+	return actual.([]fieldMapping)
+}
+
+// buildFieldMappings walks t via dbscan's shared WalkFields traversal -
+// the same one its own read-side mapper uses - so both packages resolve
+// tags, embedded/nested structs, and opaque scan-target types (time.Time,
+// sql.Scanner/driver.Valuer implementors like sql.NullString) identically,
+// and turns each leaf field it finds into a fieldMapping. Unlike
+// pgxscan.NewAPI's read-side default, StructTagPrefix is off here: an
+// untagged, non-embedded nested struct field has no business being
+// flattened into a dotted column name for an INSERT, since compileNamed's
+// ":name" placeholders have no way to bind one (a "." isn't a valid name
+// byte) - it's left as a single column instead, the same as any other
+// write-side field with no special encoding.
+func buildFieldMappings(t reflect.Type) []fieldMapping {
+	cfg := dbscan.FieldWalkConfig{
+		StructTagKey:    "db",
+		AltStructTagKey: "pgx",
+		ColumnSeparator: ".",
+		FieldMapper:     mapFieldName,
+		StructTagPrefix: false,
+	}
+	var mappings []fieldMapping
+	dbscan.WalkFields(t, cfg, "", nil, func(lf dbscan.LeafField) {
+		cast, encode := encodingForOpts(lf.Opts)
+		mappings = append(mappings, fieldMapping{
+			column: lf.Column,
+			index:  lf.Index,
+			cast:   cast,
+			encode: encode,
+		})
+	})
+	return mappings
+}
+
+// encodingForOpts inspects a field's tag options ("json", "jsonb", "array")
+// and returns the Postgres cast its placeholder should carry, if any, along
+// with the function that turns its value into a pgx argument.
+func encodingForOpts(opts []string) (cast string, encode func(reflect.Value) (interface{}, error)) {
+	for _, opt := range opts {
+		switch opt {
+		case "json":
+			return "json", encodeJSON
+		case "jsonb":
+			return "jsonb", encodeJSON
+		case "array":
+			return "", encodeArray
+		}
+	}
+	return "", defaultEncode
+}
+
+func defaultEncode(v reflect.Value) (interface{}, error) {
+	return v.Interface(), nil
+}
+
+// encodeJSON marshals v to JSON, so a map[string]any, slice or struct field
+// tagged "...,json"/"...,jsonb" round-trips against a json/jsonb column
+// without the caller writing a pgtype wrapper.
+func encodeJSON(v reflect.Value) (interface{}, error) {
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, errors.Wrap(err, "scany: marshaling json field")
+	}
+	return b, nil
+}
+
+// encodeArray renders a slice or array field tagged "...,array" as a
+// Postgres array literal ("{a,b,c}"), for element types pgx has no native
+// array encoding for.
+func encodeArray(v reflect.Value) (interface{}, error) {
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, errors.Errorf(`scany: "array" tag on non-slice field of type %s`, v.Type())
+	}
+	elems := make([]string, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		b, err := json.Marshal(v.Index(i).Interface())
+		if err != nil {
+			return nil, errors.Wrap(err, "scany: marshaling array element")
+		}
+		elems[i] = string(b)
+	}
+	return "{" + strings.Join(elems, ",") + "}", nil
+}
+
+// columnCasts returns, for struct type t, a map of column name to explicit
+// Postgres cast suffix (e.g. "jsonb") for columns whose tag requested one,
+// so generated placeholders can be written as "$n::jsonb" instead of
+// leaving pgx to infer the parameter's type from context.
+func columnCasts(t reflect.Type) map[string]string {
+	mappings := fieldMappings(t)
+	casts := make(map[string]string, len(mappings))
+	for _, m := range mappings {
+		if m.cast != "" {
+			casts[m.column] = m.cast
+		}
+	}
+	return casts
+}
+
+// fieldMappingByColumn returns t's fieldMapping for column, if any.
+func fieldMappingByColumn(t reflect.Type, column string) (*fieldMapping, bool) {
+	for _, m := range fieldMappings(t) {
+		if m.column == column {
+			mCopy := m
+			return &mCopy, true
+		}
+	}
+	return nil, false
+}
+
+// typeByIndex is reflect.Type.FieldByIndex's equivalent for a []int path as
+// used by fieldMapping.index: unlike reflect.Value.FieldByIndex, stepping
+// through a pointer field is never a problem at the type level.
+func typeByIndex(t reflect.Type, index []int) reflect.Type {
+	for _, i := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		t = t.Field(i).Type
+	}
+	return t
+}
+
+// fieldByIndex walks index from v, the same way reflect.Value.FieldByIndex
+// does, except it stops and returns the zero Value on a nil embedded
+// pointer instead of panicking.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
@@ -0,0 +1,28 @@
+package pgxscan
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSetMapper_OverridesUntaggedFieldNames confirms SetMapper's override
+// reaches buildFieldMappings, the same way Insert/NamedExec/etc. do.
+func TestSetMapper_OverridesUntaggedFieldNames(t *testing.T) {
+	type mapperWidget struct {
+		DisplayName string
+	}
+
+	orig := fieldMapperFunc
+	defer func() {
+		fieldMapperMu.Lock()
+		fieldMapperFunc = orig
+		fieldMapperMu.Unlock()
+	}()
+	SetMapper(strings.ToUpper)
+
+	mappings := fieldMappings(reflect.TypeOf(mapperWidget{}))
+	if len(mappings) != 1 || mappings[0].column != "DISPLAYNAME" {
+		t.Errorf("mappings = %+v, want a single column %q", mappings, "DISPLAYNAME")
+	}
+}
@@ -0,0 +1,361 @@
+package pgxscan
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// NamedArgs is a map of named query arguments, usable anywhere NamedExec or
+// NamedQuery accepts a struct: bindNamed resolves a query's ":name"
+// placeholders against it the same way it would against a struct's "db"-
+// tagged fields. This is this package's own type for its own named-
+// parameter machinery - it is not a substitute for pgx v5's pgx.NamedArgs,
+// which pgx.Conn.Query itself recognizes and rewrites via pgx.QueryRewriter.
+// pgx v4, which this package targets, has neither concept at all, so
+// Select/Get/Querier.Query have no
+// hook to special-case a pgx.QueryRewriter-shaped argument the way pgx v5's
+// own Query does; that would need a pgx v5 upgrade.
+type NamedArgs map[string]interface{}
+
+// NamedExec parses the `:name` placeholders in query, resolves each one
+// against arg (a struct, a pointer to struct, or a map[string]interface{}),
+// rebinds the query to positional `$1, $2, ...` placeholders and runs it
+// through db. Values are always passed through pgx as arguments, never
+// interpolated into the query text, so arg can never be used to inject SQL.
+// Any rows returned by query are discarded.
+func NamedExec(ctx context.Context, db Querier, query string, arg interface{}) error {
+	rewritten, args, err := bindNamed(query, arg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	rows, err := db.Query(ctx, rewritten, args...)
+	if err != nil {
+		return errors.Wrap(err, "scany: named exec")
+	}
+	rows.Close()
+	return errors.WithStack(rows.Err())
+}
+
+// NamedQuery is like NamedExec but scans the resulting rows into dst via
+// ScanAll, the same way Select does.
+func NamedQuery(ctx context.Context, db Querier, dst interface{}, query string, arg interface{}) error {
+	rewritten, args, err := bindNamed(query, arg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	rows, err := db.Query(ctx, rewritten, args...)
+	if err != nil {
+		return errors.Wrap(err, "scany: named query")
+	}
+	err = ScanAll(dst, rows)
+	return errors.WithStack(err)
+}
+
+// bindNamed rewrites query's `:name` placeholders to positional `$1, $2,
+// ...` ones and resolves each referenced name against arg, in the order the
+// names appear.
+func bindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	rewritten, names := compileNamed(query)
+	args, err := namedArgValues(arg, names)
+	if err != nil {
+		return "", nil, err
+	}
+	return rewritten, args, nil
+}
+
+// compileNamed scans query for `:name` placeholders and rewrites them to
+// positional `$1, $2, ...` ones, returning the names in the order they were
+// encountered (a name referenced more than once is repeated). Postgres
+// `::type` casts and anything inside single-quoted string literals are left
+// untouched.
+func compileNamed(query string) (string, []string) {
+	var rewritten strings.Builder
+	var names []string
+	inString := false
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			inString = !inString
+			rewritten.WriteByte(c)
+		case !inString && c == ':':
+			if i+1 < len(query) && query[i+1] == ':' {
+				// Postgres type cast, e.g. "foo::text" - not a bind parameter.
+				rewritten.WriteString("::")
+				i++
+				continue
+			}
+			j := i + 1
+			for j < len(query) && isNameByte(query[j]) {
+				j++
+			}
+			if j == i+1 {
+				rewritten.WriteByte(c)
+				continue
+			}
+			names = append(names, query[i+1:j])
+			rewritten.WriteString("$" + strconv.Itoa(len(names)))
+			i = j - 1
+		default:
+			rewritten.WriteByte(c)
+		}
+	}
+	return rewritten.String(), names
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// namedArgValues resolves names, in order, against arg, which may be a
+// struct, a pointer to struct, or any map keyed by string (including
+// NamedArgs).
+func namedArgValues(arg interface{}, names []string) ([]interface{}, error) {
+	var fields map[string]interface{}
+	if v := reflect.ValueOf(arg); v.Kind() == reflect.Map {
+		fields = make(map[string]interface{}, v.Len())
+		for _, key := range v.MapKeys() {
+			fields[key.String()] = v.MapIndex(key).Interface()
+		}
+	} else {
+		var err error
+		fields, err = structFields(arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		v, ok := fields[name]
+		if !ok {
+			return nil, errors.Errorf("scany: named parameter %q has no matching field in %T", name, arg)
+		}
+		args[i] = v
+	}
+	return args, nil
+}
+
+// structFields walks data, a struct or pointer to struct, flattening
+// embedded structs, and returns the value bound to each field's column
+// name. The mapping itself (which fields exist, under which column names)
+// is built once per type and cached; see mapper.go. A field tagged
+// "...,json"/"...,jsonb" is JSON-marshaled and a field tagged "...,array"
+// is rendered as a Postgres array literal.
+func structFields(data interface{}) (map[string]interface{}, error) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	mappings := fieldMappings(v.Type())
+	fields := make(map[string]interface{}, len(mappings))
+	for _, m := range mappings {
+		fv := fieldByIndex(v, m.index)
+		if !fv.IsValid() {
+			continue
+		}
+		val, err := m.encode(fv)
+		if err != nil {
+			return nil, errors.Wrapf(err, "scany: encoding field %q", m.column)
+		}
+		fields[m.column] = val
+	}
+	return fields, nil
+}
+
+func sortedColumns(fields map[string]interface{}) []string {
+	cols := make([]string, 0, len(fields))
+	for col := range fields {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// Insert builds a parameterized INSERT statement for data (a struct or
+// pointer to struct) and runs it through NamedExec, so values are always
+// sent as query arguments rather than concatenated into the SQL text.
+// additionalQuery is appended verbatim after the VALUES clause, e.g. for a
+// "RETURNING" or "ON CONFLICT" clause.
+func Insert(ctx context.Context, db Querier, data interface{}, table string, additionalQuery string) error {
+	fields, err := structFields(data)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	cols := sortedColumns(fields)
+	casts := columnCasts(derefType(data))
+	placeholders := make([]string, len(cols))
+	for i, col := range cols {
+		placeholders[i] = ":" + col + castSuffix(casts, col)
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s) %s",
+		table, strings.Join(cols, ", "), strings.Join(placeholders, ", "), additionalQuery,
+	)
+	return errors.WithStack(NamedExec(ctx, db, query, data))
+}
+
+// castSuffix returns the "::type" suffix to append to col's placeholder, or
+// "" if col has no explicit cast.
+func castSuffix(casts map[string]string, col string) string {
+	if c, ok := casts[col]; ok {
+		return "::" + c
+	}
+	return ""
+}
+
+// derefType returns data's type, dereferencing a pointer.
+func derefType(data interface{}) reflect.Type {
+	t := reflect.TypeOf(data)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// InsertOption customizes InsertMany and Upsert.
+type InsertOption func(*insertOptions)
+
+type insertOptions struct {
+	additionalQuery string
+	columns         []string
+}
+
+// WithAdditionalQuery appends query verbatim to the generated statement,
+// e.g. for a "RETURNING" clause.
+func WithAdditionalQuery(query string) InsertOption {
+	return func(o *insertOptions) { o.additionalQuery = query }
+}
+
+// WithColumns restricts the statement to columns, instead of every column
+// data's type would otherwise derive, e.g. so CopyFromStructs can carry its
+// own WithCopyColumns subset into the Upsert it falls back to for
+// WithCopyOnConflict.
+func WithColumns(columns ...string) InsertOption {
+	return func(o *insertOptions) { o.columns = columns }
+}
+
+// InsertMany expands one VALUES tuple per element of data, a slice of
+// structs or pointers to structs, into a single parameterized INSERT
+// statement executed in one round trip.
+func InsertMany(ctx context.Context, db Querier, data interface{}, table string, opts ...InsertOption) error {
+	o := &insertOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return insertRows(ctx, db, data, table, o.columns, func([]string) string {
+		return o.additionalQuery
+	})
+}
+
+// Upsert is like InsertMany but adds an "ON CONFLICT (conflictCols) DO
+// UPDATE" clause that sets every non-conflict column to its EXCLUDED value,
+// performing a PostgreSQL upsert in a single statement. If the statement's
+// columns (data's own, or the WithColumns subset) include none outside
+// conflictCols, there's
+// nothing left to set on conflict, so the clause falls back to "DO
+// NOTHING" instead of emitting an empty, invalid "DO UPDATE SET".
+func Upsert(ctx context.Context, db Querier, data interface{}, table string, conflictCols []string, opts ...InsertOption) error {
+	o := &insertOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return insertRows(ctx, db, data, table, o.columns, func(cols []string) string {
+		query := fmt.Sprintf(
+			"ON CONFLICT (%s) %s",
+			strings.Join(conflictCols, ", "), conflictAction(cols, conflictCols),
+		)
+		if o.additionalQuery != "" {
+			query += " " + o.additionalQuery
+		}
+		return query
+	})
+}
+
+// conflictAction returns the action clause for an "ON CONFLICT (...)" Upsert
+// generates: "DO UPDATE SET ..." if cols has a column outside conflictCols
+// left to set, or "DO NOTHING" if every column is a conflict column.
+func conflictAction(cols, conflictCols []string) string {
+	set := updateSetClause(cols, conflictCols)
+	if set == "" {
+		return "DO NOTHING"
+	}
+	return "DO UPDATE SET " + set
+}
+
+func updateSetClause(cols, conflictCols []string) string {
+	conflictSet := make(map[string]bool, len(conflictCols))
+	for _, c := range conflictCols {
+		conflictSet[c] = true
+	}
+	sets := make([]string, 0, len(cols))
+	for _, c := range cols {
+		if conflictSet[c] {
+			continue
+		}
+		sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", c, c))
+	}
+	return strings.Join(sets, ", ")
+}
+
+// insertRows builds a multi-row INSERT for data, a slice of structs or
+// pointers to structs, with one named placeholder per cell, and appends
+// the result of additionalQuery(cols) - which may depend on the resolved
+// column list, e.g. for an ON CONFLICT clause. columns restricts the
+// statement to that subset instead of every column data's type derives, if
+// non-empty.
+func insertRows(ctx context.Context, db Querier, data interface{}, table string, columns []string, additionalQuery func(cols []string) string) error {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return errors.New("scany: expected a slice of structs")
+	}
+	if v.Len() == 0 {
+		return nil
+	}
+
+	firstRow, err := structFields(v.Index(0).Interface())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	cols := columns
+	if len(cols) == 0 {
+		cols = sortedColumns(firstRow)
+	}
+	casts := columnCasts(derefType(v.Index(0).Interface()))
+
+	args := make(map[string]interface{}, v.Len()*len(cols))
+	tuples := make([]string, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		row := firstRow
+		if i > 0 {
+			row, err = structFields(v.Index(i).Interface())
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		placeholders := make([]string, len(cols))
+		for j, col := range cols {
+			name := fmt.Sprintf("r%d_%s", i, col)
+			args[name] = row[col]
+			placeholders[j] = ":" + name + castSuffix(casts, col)
+		}
+		tuples[i] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES %s %s",
+		table, strings.Join(cols, ", "), strings.Join(tuples, ", "), additionalQuery(cols),
+	)
+	return errors.WithStack(NamedExec(ctx, db, query, args))
+}
@@ -0,0 +1,183 @@
+package pgxscan
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestCompileNamed(t *testing.T) {
+	cases := []struct {
+		name      string
+		query     string
+		rewritten string
+		names     []string
+	}{
+		{
+			name:      "no placeholders",
+			query:     "SELECT 1",
+			rewritten: "SELECT 1",
+		},
+		{
+			name:      "single placeholder",
+			query:     "SELECT * FROM foo WHERE id = :id",
+			rewritten: "SELECT * FROM foo WHERE id = $1",
+			names:     []string{"id"},
+		},
+		{
+			name:      "repeated placeholder gets repeated positionally",
+			query:     "SELECT * FROM foo WHERE id = :id OR parent_id = :id",
+			rewritten: "SELECT * FROM foo WHERE id = $1 OR parent_id = $2",
+			names:     []string{"id", "id"},
+		},
+		{
+			name:      "a :: cast is left alone, not treated as a placeholder",
+			query:     "INSERT INTO foo (meta) VALUES (:meta::jsonb)",
+			rewritten: "INSERT INTO foo (meta) VALUES ($1::jsonb)",
+			names:     []string{"meta"},
+		},
+		{
+			name:      "a colon inside a quoted string literal is left alone",
+			query:     "SELECT * FROM foo WHERE label = 'a:b' AND id = :id",
+			rewritten: "SELECT * FROM foo WHERE label = 'a:b' AND id = $1",
+			names:     []string{"id"},
+		},
+		{
+			name:      "a lone colon with no name is left alone",
+			query:     "SELECT arr[1: 2] FROM foo",
+			rewritten: "SELECT arr[1: 2] FROM foo",
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			rewritten, names := compileNamed(tc.query)
+			if rewritten != tc.rewritten {
+				t.Errorf("rewritten = %q, want %q", rewritten, tc.rewritten)
+			}
+			if !reflect.DeepEqual(names, tc.names) {
+				t.Errorf("names = %v, want %v", names, tc.names)
+			}
+		})
+	}
+}
+
+func TestNamedArgValues_MissingField_ReturnsErr(t *testing.T) {
+	type arg struct {
+		ID int `db:"id"`
+	}
+	_, err := namedArgValues(arg{ID: 1}, []string{"id", "name"})
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+	expected := `scany: named parameter "name" has no matching field in pgxscan.arg`
+	if err.Error() != expected {
+		t.Errorf("err = %q, want %q", err.Error(), expected)
+	}
+}
+
+func TestNamedArgValues_Map(t *testing.T) {
+	args, err := namedArgValues(NamedArgs{"id": 1, "name": "foo"}, []string{"name", "id", "name"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []interface{}{"foo", 1, "foo"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("args = %v, want %v", args, expected)
+	}
+}
+
+func TestInsert_GeneratesExpectedSQL(t *testing.T) {
+	type widget struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+	q := &fakeQuerier{}
+	err := Insert(context.Background(), q, widget{ID: 1, Name: "foo"}, "widgets", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedQuery := "INSERT INTO widgets (id, name) VALUES ($1, $2) "
+	if q.query != expectedQuery {
+		t.Errorf("query = %q, want %q", q.query, expectedQuery)
+	}
+	expectedArgs := []interface{}{1, "foo"}
+	if !reflect.DeepEqual(q.args, expectedArgs) {
+		t.Errorf("args = %v, want %v", q.args, expectedArgs)
+	}
+}
+
+// TestInsert_UntaggedNonEmbeddedStructField_IsKeptAsOneColumn guards
+// against buildFieldMappings flattening a plain value-object field (not
+// time.Time, not a Scanner/Valuer, no "db" tag) into a dotted column name
+// the way the read-side mapper does for JOINs: compileNamed's ":name"
+// placeholders can't bind a dotted name, so that would produce malformed
+// SQL like ":addr.city" truncating to the "$1" placeholder "addr".
+func TestInsert_UntaggedNonEmbeddedStructField_IsKeptAsOneColumn(t *testing.T) {
+	type address struct {
+		City string
+	}
+	type widget struct {
+		ID   int `db:"id"`
+		Addr address
+	}
+	q := &fakeQuerier{}
+	err := Insert(context.Background(), q, widget{ID: 1, Addr: address{City: "NYC"}}, "widgets", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedQuery := "INSERT INTO widgets (addr, id) VALUES ($1, $2) "
+	if q.query != expectedQuery {
+		t.Errorf("query = %q, want %q", q.query, expectedQuery)
+	}
+}
+
+// TestUpsert_EveryColumnIsAConflictColumn_FallsBackToDoNothing guards
+// against updateSetClause producing an empty SET list: "ON CONFLICT (id)
+// DO UPDATE SET " with nothing after it is invalid Postgres syntax, so
+// Upsert must fall back to "DO NOTHING" when no column outside conflictCols
+// is left to set.
+func TestUpsert_EveryColumnIsAConflictColumn_FallsBackToDoNothing(t *testing.T) {
+	type widget struct {
+		ID int `db:"id"`
+	}
+	q := &fakeQuerier{}
+	err := Upsert(context.Background(), q, []widget{{ID: 1}}, "widgets", []string{"id"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(q.query, "ON CONFLICT (id) DO NOTHING") {
+		t.Errorf("query = %q, want a well-formed ON CONFLICT (id) DO NOTHING clause", q.query)
+	}
+}
+
+// fakeQuerier is a Querier that records the last query it was asked to run
+// instead of executing anything, so named.go's SQL generation can be tested
+// without a real database.
+type fakeQuerier struct {
+	query string
+	args  []interface{}
+}
+
+func (q *fakeQuerier) Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	q.query = query
+	q.args = args
+	return &fakeRows{}, nil
+}
+
+// fakeRows is an empty pgx.Rows that never yields a row.
+type fakeRows struct{}
+
+func (r *fakeRows) Close()                                         {}
+func (r *fakeRows) Err() error                                     { return nil }
+func (r *fakeRows) CommandTag() pgconn.CommandTag                  { return nil }
+func (r *fakeRows) FieldDescriptions() []pgproto3.FieldDescription { return nil }
+func (r *fakeRows) Next() bool                                     { return false }
+func (r *fakeRows) Scan(dest ...interface{}) error                 { return nil }
+func (r *fakeRows) Values() ([]interface{}, error)                 { return nil, nil }
+func (r *fakeRows) RawValues() [][]byte                            { return nil }
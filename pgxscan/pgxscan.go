@@ -2,10 +2,7 @@ package pgxscan
 
 import (
 	"context"
-	"fmt"
-	"reflect"
 	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/jackc/pgx/v4"
@@ -39,100 +36,35 @@ func toSnakeCase(str string) string {
 }
 
 // Select is a high-level function that queries rows from Querier and calls the ScanAll function.
-// See ScanAll for details.
+// See ScanAll for details. args is forwarded to db.Query unchanged, so it
+// accepts whatever positional arguments the underlying pgx v4 Querier does.
+// It is not a hook for a single NamedArgs or pgx.QueryRewriter-shaped
+// argument: pgx v4 has no pgx.NamedArgs/pgx.QueryRewriter for Query to
+// recognize and rewrite the way pgx v5's does, so there's nothing here for
+// Select to special-case without a pgx v5 upgrade. Use NamedQuery to run a
+// ":name"-style query against a NamedArgs or struct instead.
 func Select(ctx context.Context, db Querier, dst interface{}, query string, args ...interface{}) error {
-	rows, err := db.Query(ctx, query, args...)
-	if err != nil {
-		return errors.Wrap(err, "scany: query multiple result rows")
-	}
-	err = ScanAll(dst, rows)
-	return errors.WithStack(err)
-}
-
-// Basic Insert function to allow for inserting structs
-func Insert(ctx context.Context, db Querier, data interface{}, table string, additionalQuery string) error {
-
-	fields := reflect.TypeOf(data)
-	values := reflect.ValueOf(data)
-
-	num := fields.NumField()
-	var dbCols []string
-	var dbVals []string
-
-	for i := 0; i < num; i++ {
-		field := fields.Field(i)
-		value := values.Field(i)
-
-		val, exists := field.Tag.Lookup("pgx")
-
-		if exists {
-			dbCols = append(dbCols, val)
-		}else{
-			dbCols = append(dbCols, toSnakeCase(field.Name))
-		}
-
-		var v string
-
-		switch value.Kind() {
-		case reflect.String:
-			v = "'" + value.String() + "'"
-		case reflect.Int:
-			v = strconv.FormatInt(value.Int(), 10)
-		case reflect.Int8:
-			v = strconv.FormatInt(value.Int(), 10)
-		case reflect.Int32:
-			v = strconv.FormatInt(value.Int(), 10)
-		case reflect.Int64:
-			v = strconv.FormatInt(value.Int(), 10)
-		case reflect.Float64:
-			v = fmt.Sprintf("%f", value.Float())
-		case reflect.Float32:
-			v = fmt.Sprintf("%f", value.Float())
-		default:
-			return errors.Wrap(errors.New("type: " + value.Kind().String() + " unsupported"), "scany: this type not yet supported")
-		}
-
-		dbVals = append(dbVals, v)
-
-	}
-
-	sql := "INSERT INTO " + table + " (" + strings.Join(dbCols, ", ") + ") VALUES (" + strings.Join(dbVals, ", ") + ") " + additionalQuery
-
-	_, err := db.Query(ctx, sql)
-	if err != nil {
-		return errors.Wrap(err, "scany: insertion error")
-	}
-
-	return errors.WithStack(err)
+	return defaultAPI.Select(ctx, db, dst, query, args...)
 }
 
 // Get is a high-level function that queries rows from Querier and calls the ScanOne function.
-// See ScanOne for details.
+// See ScanOne for details. args is forwarded to db.Query unchanged; see
+// Select.
 func Get(ctx context.Context, db Querier, dst interface{}, query string, args ...interface{}) error {
-	rows, err := db.Query(ctx, query, args...)
-	if err != nil {
-		return errors.Wrap(err, "scany: query one result row")
-	}
-	err = ScanOne(dst, rows)
-	return errors.WithStack(err)
+	return defaultAPI.Get(ctx, db, dst, query, args...)
 }
 
-// ScanAll is a wrapper around the dbscan.ScanAll function.
-// See dbscan.ScanAll for details.
+// ScanAll is a wrapper around the dbscan.API.ScanAll method. See ScanAll for
+// details on how dst's struct fields, including nested ones reached through
+// a tagged field, are matched to columns.
 func ScanAll(dst interface{}, rows pgx.Rows) error {
-	err := dbscan.ScanAll(dst, NewRowsAdapter(rows))
-	return errors.WithStack(err)
+	return defaultAPI.ScanAll(dst, rows)
 }
 
-// ScanOne is a wrapper around the dbscan.ScanOne function.
-// See dbscan.ScanOne for details. If no rows are found it
-// returns a pgx.ErrNoRows error.
+// ScanOne is a wrapper around the dbscan.API.ScanOne method.
+// If no rows are found it returns a pgx.ErrNoRows error.
 func ScanOne(dst interface{}, rows pgx.Rows) error {
-	err := dbscan.ScanOne(dst, NewRowsAdapter(rows))
-	if dbscan.NotFound(err) {
-		return errors.WithStack(pgx.ErrNoRows)
-	}
-	return errors.WithStack(err)
+	return defaultAPI.ScanOne(dst, rows)
 }
 
 // NotFound is a helper function to check if an error
@@ -149,19 +81,19 @@ type RowScanner struct {
 
 // NewRowScanner returns a new RowScanner instance.
 func NewRowScanner(rows pgx.Rows) *RowScanner {
-	ra := NewRowsAdapter(rows)
-	return &RowScanner{RowScanner: dbscan.NewRowScanner(ra)}
+	return defaultAPI.NewRowScanner(rows)
 }
 
-// ScanRow is a wrapper around the dbscan.ScanRow function.
-// See dbscan.ScanRow for details.
+// ScanRow is a wrapper around the dbscan.API.ScanRow method.
 func ScanRow(dst interface{}, rows pgx.Rows) error {
-	err := dbscan.ScanRow(dst, NewRowsAdapter(rows))
-	return errors.WithStack(err)
+	return defaultAPI.ScanRow(dst, rows)
 }
 
 // RowsAdapter makes pgx.Rows compliant with the dbscan.Rows interface.
-// See dbscan.Rows for details.
+// See dbscan.Rows for details. A destination field tagged "...,json",
+// "...,jsonb" or "...,array" - the read-side counterpart of the encoding
+// named.go does for Insert - is decoded by dbscan itself, which owns
+// per-field assignment into dst.
 type RowsAdapter struct {
 	pgx.Rows
 }